@@ -0,0 +1,285 @@
+package pstore
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Backend is a byte-oriented key/value store. PersistentStorage composes a
+// Backend to decide where serialized entries actually live, so the
+// filesystem can be swapped for an in-memory map, SQLite, or a remote store
+// without touching the typed Get/Set API.
+type Backend interface {
+	// Get returns the raw bytes stored under key, or ErrBackendKeyNotFound if key is absent.
+	Get(key string) ([]byte, error)
+	// Set stores data under key, overwriting any existing value.
+	Set(key string, data []byte) error
+	// Delete removes key. It is not an error to delete a key that doesn't exist.
+	Delete(key string) error
+	// List returns every key currently stored, in no particular order.
+	List() ([]string, error)
+}
+
+// ErrBackendKeyNotFound is returned by a Backend's Get when the key isn't present.
+var ErrBackendKeyNotFound = errors.New("pstore: backend key not found")
+
+type fsBackend struct {
+	path string
+	name string
+}
+
+// NewFSBackend creates a Backend that stores each key as a separate
+// "<name>_<key>.pcache" file under path, mirroring PersistentStorage's
+// default on-disk layout.
+func NewFSBackend(path, name string) Backend {
+	return &fsBackend{path: path, name: name}
+}
+
+func (b *fsBackend) filename(key string) string {
+	return b.name + "_" + key + cache_ext
+}
+
+func (b *fsBackend) fullPath(key string) string {
+	return path.Join(b.path, b.filename(key))
+}
+
+func (b *fsBackend) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(b.fullPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBackendKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (b *fsBackend) Set(key string, data []byte) error {
+	return atomicWriteFile(b.fullPath(key), data, 0644)
+}
+
+func (b *fsBackend) Delete(key string) error {
+	return os.RemoveAll(b.fullPath(key))
+}
+
+func (b *fsBackend) List() ([]string, error) {
+	files, err := os.ReadDir(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := b.name + "_"
+	keys := []string{}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(file.Name(), prefix) && strings.HasSuffix(file.Name(), cache_ext) {
+			keys = append(keys, strings.TrimSuffix(strings.TrimPrefix(file.Name(), prefix), cache_ext))
+		}
+	}
+
+	return keys, nil
+}
+
+type memoryBackend struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+// NewMemoryBackend creates a Backend that keeps everything in a plain map, useful as a front for NewCached or standalone for tests.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{data: make(map[string][]byte)}
+}
+
+func (b *memoryBackend) Get(key string) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	data, ok := b.data[key]
+	if !ok {
+		return nil, ErrBackendKeyNotFound
+	}
+
+	return data, nil
+}
+
+func (b *memoryBackend) Set(key string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.data[key] = data
+	return nil
+}
+
+func (b *memoryBackend) Delete(key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.data, key)
+	return nil
+}
+
+func (b *memoryBackend) List() ([]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	keys := make([]string, 0, len(b.data))
+	for k := range b.data {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// boundedFrontBackend is a memoryBackend capped at maxItems entries, evicting
+// the least recently used one (via the same lruPolicy NewLRUPolicy() exposes)
+// once the cap is reached. Used as NewCached's default front in New() so the
+// read-through cache doesn't retain every key ever touched for the lifetime
+// of the process. maxItems <= 0 means unlimited, same as memoryBackend.
+type boundedFrontBackend struct {
+	mutex    sync.Mutex
+	data     map[string][]byte
+	maxItems int
+	lru      EvictionPolicy
+}
+
+func newBoundedFrontBackend(maxItems int) Backend {
+	return &boundedFrontBackend{
+		data:     make(map[string][]byte),
+		maxItems: maxItems,
+		lru:      NewLRUPolicy(),
+	}
+}
+
+func (b *boundedFrontBackend) Get(key string) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	data, ok := b.data[key]
+	if !ok {
+		return nil, ErrBackendKeyNotFound
+	}
+
+	b.lru.OnGet(key)
+	return data, nil
+}
+
+func (b *boundedFrontBackend) Set(key string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, existed := b.data[key]; !existed && b.maxItems > 0 {
+		for len(b.data) >= b.maxItems {
+			evictKey, ok := b.lru.Evict()
+			if !ok {
+				break
+			}
+
+			delete(b.data, evictKey)
+		}
+	}
+
+	b.data[key] = data
+	b.lru.OnSet(key)
+
+	return nil
+}
+
+func (b *boundedFrontBackend) Delete(key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.data, key)
+	b.lru.OnDelete(key)
+
+	return nil
+}
+
+func (b *boundedFrontBackend) List() ([]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	keys := make([]string, 0, len(b.data))
+	for k := range b.data {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+type cachedBackend struct {
+	front Backend
+	back  Backend
+}
+
+// NewCached composes two backends into one read-through/write-through
+// Backend: reads miss in front, fault in from back and populate front;
+// writes and deletes go to both. List is served from back, since front is
+// only ever a partial view.
+func NewCached(front, back Backend) Backend {
+	return &cachedBackend{front: front, back: back}
+}
+
+func (b *cachedBackend) Get(key string) ([]byte, error) {
+	data, err := b.front.Get(key)
+	if err == nil {
+		return data, nil
+	}
+
+	if !errors.Is(err, ErrBackendKeyNotFound) {
+		return nil, err
+	}
+
+	data, err = b.back.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.front.Set(key, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (b *cachedBackend) Set(key string, data []byte) error {
+	if err := b.front.Set(key, data); err != nil {
+		return err
+	}
+
+	return b.back.Set(key, data)
+}
+
+func (b *cachedBackend) Delete(key string) error {
+	if err := b.front.Delete(key); err != nil {
+		return err
+	}
+
+	return b.back.Delete(key)
+}
+
+func (b *cachedBackend) List() ([]string, error) {
+	return b.back.List()
+}
+
+// uncached returns the backing store underneath front, so integrity checks
+// can observe real on-disk state instead of a possibly-stale cached copy.
+func (b *cachedBackend) uncached() Backend {
+	return b.back
+}
+
+// uncachedBackend is implemented by a Backend that fronts another store with
+// an in-memory cache. VerifyAll/Repair use it to bypass that cache, since a
+// cache hit would otherwise hide corruption that happened on disk after the
+// key was last read or written.
+type uncachedBackend interface {
+	uncached() Backend
+}
@@ -0,0 +1,68 @@
+package pstore
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test_BoundedFrontBackend_EvictsLeastRecentlyUsed is a white-box regression
+// test for a bug where New()'s default front cache was a plain, unbounded
+// map, so it retained the serialized bytes of every key ever touched for the
+// lifetime of the process.
+func Test_BoundedFrontBackend_EvictsLeastRecentlyUsed(t *testing.T) {
+	b := newBoundedFrontBackend(2)
+
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if err := b.Set(key, []byte("value")); err != nil {
+			t.Fatalf("failed to set key: %v", err)
+		}
+	}
+
+	keys, err := b.List()
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+
+	if len(keys) > 2 {
+		t.Errorf("expected at most 2 resident keys, got %d", len(keys))
+	}
+
+	if _, err := b.Get("key-0"); err == nil {
+		t.Errorf("expected key-0 to have been evicted as least recently used")
+	}
+
+	if _, err := b.Get("key-4"); err != nil {
+		t.Errorf("expected most recently set key to still be resident: %v", err)
+	}
+}
+
+// Test_DefaultBackend_FrontCacheTracksMaxMemItems is a white-box regression
+// test for a bug where New()'s front cache was sized independently of
+// MaxMemItems, so "bounded memory" didn't actually hold for the backend
+// layer: every key ever touched stayed resident in front regardless of the
+// configured limit.
+func Test_DefaultBackend_FrontCacheTracksMaxMemItems(t *testing.T) {
+	p := New(t.TempDir(), "CacheName")
+	p.MaxMemItems = MemoryItemsCount(2)
+
+	for i := 0; i < 5; i++ {
+		if err := p.Set(fmt.Sprintf("key-%d", i), "value"); err != nil {
+			t.Fatalf("failed to set key: %v", err)
+		}
+	}
+
+	cached, ok := p.defaultBackend().(*cachedBackend)
+	if !ok {
+		t.Fatalf("expected defaultBackend to return a *cachedBackend, got %T", p.defaultBackend())
+	}
+
+	keys, err := cached.front.List()
+	if err != nil {
+		t.Fatalf("failed to list front keys: %v", err)
+	}
+
+	if len(keys) > 2 {
+		t.Errorf("expected front cache capped at MaxMemItems (2), got %d resident keys", len(keys))
+	}
+}
@@ -0,0 +1,126 @@
+package pstore
+
+import "reflect"
+
+// Batch queues Sets and Deletes to be applied together when Commit is
+// called. Against a SingleCacheFile store this coalesces many changes into
+// one atomic file rewrite instead of one per key; against the default
+// per-key backend, Commit just applies each change in turn since every file
+// write is already its own atomic operation.
+type Batch struct {
+	ps      *PersistentStorage
+	sets    map[string]any
+	deletes map[string]bool
+}
+
+// Batch creates a new Batch for queuing writes and deletes to apply together.
+//
+// Returns:
+//   - A new, empty Batch.
+func (ps *PersistentStorage) Batch() *Batch {
+	return &Batch{
+		ps:      ps,
+		sets:    make(map[string]any),
+		deletes: make(map[string]bool),
+	}
+}
+
+// Set queues key to be set to value when Commit is called.
+//
+// Parameters:
+//   - key: The key to set.
+//   - value: The value to set. Should not be a pointer.
+//
+// Returns:
+//   - The batch, for chaining.
+func (b *Batch) Set(key string, value any) *Batch {
+	for reflect.TypeOf(value).Kind() == reflect.Ptr {
+		value = reflect.ValueOf(value).Elem().Interface()
+	}
+
+	delete(b.deletes, key)
+	b.sets[key] = value
+
+	return b
+}
+
+// Delete queues key to be deleted when Commit is called.
+//
+// Parameters:
+//   - key: The key to delete.
+//
+// Returns:
+//   - The batch, for chaining.
+func (b *Batch) Delete(key string) *Batch {
+	delete(b.sets, key)
+	b.deletes[key] = true
+
+	return b
+}
+
+// Commit applies every queued Set and Delete. If the cache is thread-safe, the whole batch is applied atomically with respect to other operations on the same PersistentStorage.
+//
+// Returns:
+//   - An error if any queued change could not be applied. Earlier changes in the batch may have already taken effect.
+func (b *Batch) Commit() error {
+	ps := b.ps
+
+	unlock := ps.lockAll()
+	defer unlock()
+
+	if ps.inMemory {
+		for key, value := range b.sets {
+			if err := ps.set(ps.shardFor(key), key, value, 0); err != nil {
+				return err
+			}
+		}
+
+		for key := range b.deletes {
+			ps.evictFromMem(ps.shardFor(key), key)
+		}
+
+		return nil
+	}
+
+	writer, ok := ps.diskBackend().(BatchWriter)
+	if !ok {
+		for key, value := range b.sets {
+			if err := ps.set(ps.shardFor(key), key, value, 0); err != nil {
+				return err
+			}
+		}
+
+		for key := range b.deletes {
+			ps.evictFromMem(ps.shardFor(key), key)
+			if err := ps.diskBackend().Delete(key); err != nil {
+				return ps.errorf("%s %v: %v", error_delete_failed, key, err)
+			}
+		}
+
+		return nil
+	}
+
+	sets := make(map[string][]byte, len(b.sets))
+	for key, value := range b.sets {
+		expiresAt := ps.setInMemory(ps.shardFor(key), key, value, 0)
+
+		framed, err := ps.encodeForDisk(value, expiresAt)
+		if err != nil {
+			return ps.errorf("%s %v: %v", error_serialize_failed, key, err)
+		}
+
+		sets[key] = framed
+	}
+
+	deletes := make([]string, 0, len(b.deletes))
+	for key := range b.deletes {
+		deletes = append(deletes, key)
+		ps.evictFromMem(ps.shardFor(key), key)
+	}
+
+	if err := writer.ApplyBatch(sets, deletes); err != nil {
+		return ps.errorf("%s: %v", error_save_to_disk_failed, err)
+	}
+
+	return nil
+}
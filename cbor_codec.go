@@ -0,0 +1,21 @@
+package pstore
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBORCodec encodes values with CBOR (RFC 8949). Like JSONCodec it can decode
+// into a plain interface{}, which Recode relies on.
+type CBORCodec struct{}
+
+func (CBORCodec) Name() string {
+	return "cbor"
+}
+
+func (CBORCodec) Marshal(value any) ([]byte, error) {
+	return cbor.Marshal(value)
+}
+
+func (CBORCodec) Unmarshal(data []byte, out any) error {
+	return cbor.Unmarshal(data, out)
+}
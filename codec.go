@@ -0,0 +1,193 @@
+package pstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Codec controls how a value is turned into bytes (and back) before being
+// wrapped in a cacheEnvelope. PersistentStorage.Codec defaults to GobCodec.
+type Codec interface {
+	Marshal(value any) ([]byte, error)
+	Unmarshal(data []byte, out any) error
+	Name() string
+}
+
+// JSONCodec encodes values with encoding/json. Unlike GobCodec it can decode
+// into a plain interface{}, which Recode relies on when migrating away from it.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+func (JSONCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Unmarshal(data []byte, out any) error {
+	return json.Unmarshal(data, out)
+}
+
+// CompressingCodec wraps another Codec with gzip compression. Its Name is the
+// inner codec's name with a "+gzip" suffix, so readFromDisk can resolve it
+// back to the same Inner/Level-less codec via codecByName.
+type CompressingCodec struct {
+	Inner Codec
+	Level int
+}
+
+func (c CompressingCodec) Name() string {
+	return c.Inner.Name() + "+gzip"
+}
+
+func (c CompressingCodec) Marshal(value any) ([]byte, error) {
+	payload, err := c.Inner.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	buf := new(bytes.Buffer)
+	writer, err := gzip.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(payload); err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c CompressingCodec) Unmarshal(data []byte, out any) error {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return c.Inner.Unmarshal(payload, out)
+}
+
+// codecByName resolves a codec name written into a cacheEnvelope back to a
+// Codec instance. It returns nil for unrecognized names.
+func codecByName(name string) Codec {
+	if strings.HasSuffix(name, "+gzip") {
+		inner := codecByName(strings.TrimSuffix(name, "+gzip"))
+		if inner == nil {
+			return nil
+		}
+
+		return CompressingCodec{Inner: inner}
+	}
+
+	switch name {
+	case (GobCodec{}).Name():
+		return GobCodec{}
+	case (JSONCodec{}).Name():
+		return JSONCodec{}
+	case (CBORCodec{}).Name():
+		return CBORCodec{}
+	default:
+		return nil
+	}
+}
+
+// Recode rewrites every entry on disk with newCodec and makes it the codec
+// used for future reads and writes. Entries are decoded with their original
+// codec into a generic interface{} and re-encoded with newCodec, so this only
+// works for codecs that can unmarshal into an empty interface; GobCodec
+// generally can't without gob.Register, and Recode will surface that as a
+// deserialize error on the affected keys rather than skip them silently.
+//
+// Parameters:
+//   - newCodec: The codec every entry should be rewritten with.
+//
+// Returns:
+//   - An error if the keys could not be enumerated or an entry could not be recoded.
+func (ps *PersistentStorage) Recode(newCodec Codec) error {
+	unlock := ps.lockAll()
+	defer unlock()
+
+	keys, err := ps.diskBackend().List()
+	if err != nil {
+		return ps.errorf("%s: %v", error_read_files_failed, err)
+	}
+
+	for _, key := range keys {
+		raw, err := ps.diskBackend().Get(key)
+		if err != nil {
+			return ps.errorf("%s %v: %v", error_read_from_disk_failed, key, err)
+		}
+
+		envelopeBytes, isFrame, err := decodeFrame(raw)
+		if isFrame && err != nil {
+			return ps.errorf("%s %v: %v", error_corrupted_entry, key, err)
+		}
+		if !isFrame {
+			envelopeBytes = raw
+		}
+
+		env, err := decodeEnvelope(envelopeBytes)
+		if err != nil {
+			continue
+		}
+
+		oldCodecName := env.Codec
+		if oldCodecName == "" {
+			oldCodecName = (GobCodec{}).Name()
+		}
+
+		oldCodec := codecByName(oldCodecName)
+		if oldCodec == nil {
+			return ps.errorf("%s %v: unknown codec %q", error_deserialize_failed, key, oldCodecName)
+		}
+
+		var value any
+		if err := oldCodec.Unmarshal(env.Payload, &value); err != nil {
+			return ps.errorf("%s %v: %v", error_deserialize_failed, key, err)
+		}
+
+		payload, err := newCodec.Marshal(value)
+		if err != nil {
+			return ps.errorf("%s %v: %v", error_serialize_failed, key, err)
+		}
+
+		newEnvelopeBytes, err := encodeEnvelope(cacheEnvelope{
+			CreatedAt: env.CreatedAt,
+			ExpiresAt: env.ExpiresAt,
+			Payload:   payload,
+			Codec:     newCodec.Name(),
+		})
+		if err != nil {
+			return ps.errorf("%s %v: %v", error_serialize_failed, key, err)
+		}
+
+		if err := ps.diskBackend().Set(key, encodeFrame(newEnvelopeBytes)); err != nil {
+			return ps.errorf("%s %v: %v", error_save_to_disk_failed, key, err)
+		}
+	}
+
+	ps.Codec = newCodec
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package pstore
+
+import "container/list"
+
+// EvictionPolicy decides which key should be reclaimed when the in-memory
+// cache exceeds MaxMemItems or MaxMemBytes. Implementations are notified of
+// every access so they can maintain their own bookkeeping.
+type EvictionPolicy interface {
+	// OnGet is called whenever key is read from the in-memory cache.
+	OnGet(key string)
+	// OnSet is called whenever key is written to the in-memory cache.
+	OnSet(key string)
+	// OnDelete is called whenever key is removed from the in-memory cache, including evictions.
+	OnDelete(key string)
+	// Evict picks a key to reclaim. ok is false if there is nothing left to evict.
+	Evict() (key string, ok bool)
+}
+
+func (ps *PersistentStorage) itemSize(value any) (int, error) {
+	bytes, err := ps.codec().Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(bytes), nil
+}
+
+type lruPolicy struct {
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// NewLRUPolicy creates an EvictionPolicy that reclaims the least recently used key first.
+func NewLRUPolicy() EvictionPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) touch(key string) {
+	if elem, ok := p.elems[key]; ok {
+		p.order.MoveToFront(elem)
+		return
+	}
+
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) OnGet(key string) {
+	p.touch(key)
+}
+
+func (p *lruPolicy) OnSet(key string) {
+	p.touch(key)
+}
+
+func (p *lruPolicy) OnDelete(key string) {
+	elem, ok := p.elems[key]
+	if !ok {
+		return
+	}
+
+	p.order.Remove(elem)
+	delete(p.elems, key)
+}
+
+func (p *lruPolicy) Evict() (string, bool) {
+	elem := p.order.Back()
+	if elem == nil {
+		return "", false
+	}
+
+	key := elem.Value.(string)
+	p.order.Remove(elem)
+	delete(p.elems, key)
+
+	return key, true
+}
+
+// lfuFreqNode holds every key currently seen exactly freq times, kept in a
+// doubly-linked list ordered by ascending frequency.
+type lfuFreqNode struct {
+	freq int
+	keys *list.List
+}
+
+type lfuEntry struct {
+	freqNode *list.Element
+	keyElem  *list.Element
+}
+
+type lfuPolicy struct {
+	freqs   *list.List
+	entries map[string]*lfuEntry
+}
+
+// NewLFUPolicy creates an EvictionPolicy that reclaims the least frequently used key first, breaking ties by least recent use within the same frequency.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{
+		freqs:   list.New(),
+		entries: make(map[string]*lfuEntry),
+	}
+}
+
+func (p *lfuPolicy) insertNew(key string) {
+	frontElem := p.freqs.Front()
+	var frontNode *lfuFreqNode
+	if frontElem != nil {
+		frontNode = frontElem.Value.(*lfuFreqNode)
+	}
+
+	if frontNode == nil || frontNode.freq != 1 {
+		frontNode = &lfuFreqNode{freq: 1, keys: list.New()}
+		frontElem = p.freqs.PushFront(frontNode)
+	}
+
+	keyElem := frontNode.keys.PushFront(key)
+	p.entries[key] = &lfuEntry{freqNode: frontElem, keyElem: keyElem}
+}
+
+func (p *lfuPolicy) bump(key string) {
+	entry, ok := p.entries[key]
+	if !ok {
+		p.insertNew(key)
+		return
+	}
+
+	oldElem := entry.freqNode
+	oldNode := oldElem.Value.(*lfuFreqNode)
+
+	nextElem := oldElem.Next()
+	var nextNode *lfuFreqNode
+	if nextElem != nil {
+		nextNode = nextElem.Value.(*lfuFreqNode)
+	}
+
+	if nextNode == nil || nextNode.freq != oldNode.freq+1 {
+		nextNode = &lfuFreqNode{freq: oldNode.freq + 1, keys: list.New()}
+		nextElem = p.freqs.InsertAfter(nextNode, oldElem)
+	}
+
+	oldNode.keys.Remove(entry.keyElem)
+	entry.keyElem = nextNode.keys.PushFront(key)
+	entry.freqNode = nextElem
+
+	if oldNode.keys.Len() == 0 {
+		p.freqs.Remove(oldElem)
+	}
+}
+
+func (p *lfuPolicy) OnGet(key string) {
+	p.bump(key)
+}
+
+func (p *lfuPolicy) OnSet(key string) {
+	p.bump(key)
+}
+
+func (p *lfuPolicy) OnDelete(key string) {
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+
+	node := entry.freqNode.Value.(*lfuFreqNode)
+	node.keys.Remove(entry.keyElem)
+	if node.keys.Len() == 0 {
+		p.freqs.Remove(entry.freqNode)
+	}
+
+	delete(p.entries, key)
+}
+
+func (p *lfuPolicy) Evict() (string, bool) {
+	elem := p.freqs.Front()
+	if elem == nil {
+		return "", false
+	}
+
+	node := elem.Value.(*lfuFreqNode)
+	tail := node.keys.Back()
+	if tail == nil {
+		return "", false
+	}
+
+	key := tail.Value.(string)
+	node.keys.Remove(tail)
+	delete(p.entries, key)
+
+	if node.keys.Len() == 0 {
+		p.freqs.Remove(elem)
+	}
+
+	return key, true
+}
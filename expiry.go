@@ -0,0 +1,186 @@
+package pstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"time"
+)
+
+// cacheEnvelope is the on-disk wrapper around a serialized value. It carries
+// enough metadata to support expiration without changing the payload codec.
+type cacheEnvelope struct {
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Payload   []byte
+	// Codec is the Name() of the Codec that produced Payload. Empty for
+	// envelopes written before pluggable codecs were added, which means gob.
+	Codec string
+}
+
+func (e cacheEnvelope) isExpired() bool {
+	return !e.ExpiresAt.IsZero() && time.Now().After(e.ExpiresAt)
+}
+
+func encodeEnvelope(env cacheEnvelope) ([]byte, error) {
+	writer := new(bytes.Buffer)
+	enc := gob.NewEncoder(writer)
+	err := enc.Encode(env)
+
+	return writer.Bytes(), err
+}
+
+// decodeEnvelope decodes bytes written by encodeEnvelope. Files written
+// before TTL support was added are not envelopes and will fail to decode
+// here; callers should fall back to treating the bytes as a raw payload.
+func decodeEnvelope(data []byte) (cacheEnvelope, error) {
+	var env cacheEnvelope
+	reader := bytes.NewReader(data)
+	dec := gob.NewDecoder(reader)
+	err := dec.Decode(&env)
+
+	return env, err
+}
+
+// evictIfExpired removes key from memory and disk if its TTL has elapsed.
+func (ps *PersistentStorage) evictIfExpired(shard *cacheShard, key string) {
+	expiresAt, ok := shard.expiry[key]
+	if !ok || time.Now().Before(expiresAt) {
+		return
+	}
+
+	ps.evictFromMem(shard, key)
+
+	if !ps.inMemory {
+		ps.diskBackend().Delete(key)
+	}
+}
+
+// isFileExpired reports whether the on-disk entry for key carries an
+// expiration that has elapsed. Legacy files without an envelope are never
+// considered expired.
+func (ps *PersistentStorage) isFileExpired(key string) bool {
+	data, err := ps.diskBackend().Get(key)
+	if err != nil {
+		return false
+	}
+
+	envelopeBytes, isFrame, err := decodeFrame(data)
+	if isFrame && err != nil {
+		return false
+	}
+	if !isFrame {
+		envelopeBytes = data
+	}
+
+	env, err := decodeEnvelope(envelopeBytes)
+	if err != nil {
+		return false
+	}
+
+	return env.isExpired()
+}
+
+// SetWithTTL sets the value of the key in the cache with an expiration. Once the TTL elapses, the entry is treated as missing by Get/Has/Keys/Len and is purged lazily on the next access that touches it, or eagerly by StartExpiryReaper.
+//
+// Parameters:
+//   - key: The key to set.
+//   - value: The value to set. Should not be a pointer.
+//   - ttl: How long the entry stays valid. A zero or negative value means no expiration.
+//
+// Returns:
+//   - An error if the value could not be set.
+func (ps *PersistentStorage) SetWithTTL(key string, value any, ttl time.Duration) error {
+	shard := ps.shardFor(key)
+	unlock := ps.lock(shard)
+	defer unlock()
+
+	var it any = value
+
+	for reflect.TypeOf(it).Kind() == reflect.Ptr {
+		it = reflect.ValueOf(it).Elem().Interface()
+	}
+
+	return ps.set(shard, key, it, ttl)
+}
+
+// GetWithMetadata gets the value of the key from the cache, same as Get, and additionally reports how much longer the entry remains valid.
+//
+// Parameters:
+//   - key: The key to get.
+//   - out: The value to get. Must be a pointer.
+//
+// Returns:
+//   - The remaining TTL for the entry. Zero if the entry has no expiration.
+//   - An error if the value could not be retrieved.
+func (ps *PersistentStorage) GetWithMetadata(key string, out any) (time.Duration, error) {
+	shard := ps.shardFor(key)
+	unlock := ps.lock(shard)
+	defer unlock()
+
+	if err := ps.get(shard, out, key); err != nil {
+		return 0, err
+	}
+
+	expiresAt, ok := shard.expiry[key]
+	if !ok {
+		return 0, nil
+	}
+
+	return time.Until(expiresAt), nil
+}
+
+// StartExpiryReaper launches a background goroutine that periodically purges expired .pcache files from disk and memory. It is opt-in: without calling this, expired entries are only purged lazily as they're accessed.
+//
+// Parameters:
+//   - interval: How often the reaper scans for expired entries.
+//
+// Returns:
+//   - A function that stops the reaper. The reaper keeps running until it is called.
+func (ps *PersistentStorage) StartExpiryReaper(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				ps.reapExpired()
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+func (ps *PersistentStorage) reapExpired() {
+	unlock := ps.lockAll()
+	defer unlock()
+
+	for _, shard := range ps.shardSet() {
+		for key := range shard.expiry {
+			ps.evictIfExpired(shard, key)
+		}
+	}
+
+	if ps.inMemory {
+		return
+	}
+
+	keys, err := ps.diskBackend().List()
+	if err != nil {
+		return
+	}
+
+	for _, key := range keys {
+		if ps.isFileExpired(key) {
+			ps.diskBackend().Delete(key)
+			ps.evictFromMem(ps.shardFor(key), key)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package pstore
+
+import (
+	"testing"
+	"time"
+)
+
+// Test_Get_DoesNotCacheZeroValueOnExpiry is a white-box regression test for a
+// bug where get() cached the zero value for a key whose TTL had just
+// expired, so every later Get/Has on that key silently succeeded with the
+// zero value instead of re-checking disk.
+func Test_Get_DoesNotCacheZeroValueOnExpiry(t *testing.T) {
+	p := NewInMemory("InternalTestCache")
+
+	if err := p.SetWithTTL("key", "value", time.Millisecond); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	var out string
+	if err := p.Get("key", &out); err == nil {
+		t.Fatalf("expected expired key to return an error, got value %q", out)
+	}
+
+	shard := p.shardFor("key")
+	if _, ok := shard.cache["key"]; ok {
+		t.Errorf("expected expired key not to be cached after a failed Get, but it was poisoned with the zero value")
+	}
+}
@@ -0,0 +1,166 @@
+package pstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+const frameMagic = "PCF1"
+const frameVersion byte = 1
+
+var headerSize = len(frameMagic) + 1 + 4
+
+const checksumSize = sha256.Size
+
+var errFrameTruncated = errors.New("frame truncated")
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// encodeFrame wraps payload in a magic+version+length header and a trailing
+// checksum over the header and payload, so bitrot on disk can be detected on
+// read instead of silently corrupting a decoded value.
+func encodeFrame(payload []byte) []byte {
+	header := make([]byte, headerSize)
+	copy(header, frameMagic)
+	header[len(frameMagic)] = frameVersion
+	binary.BigEndian.PutUint32(header[len(frameMagic)+1:], uint32(len(payload)))
+
+	sum := sha256.Sum256(append(append([]byte{}, header...), payload...))
+
+	frame := make([]byte, 0, headerSize+len(payload)+checksumSize)
+	frame = append(frame, header...)
+	frame = append(frame, payload...)
+	frame = append(frame, sum[:]...)
+
+	return frame
+}
+
+// decodeFrame reverses encodeFrame. isFrame is false when data doesn't start
+// with a recognized frame header at all, meaning it's a file written before
+// checksums were introduced and should be read as a raw legacy payload. When
+// isFrame is true but err is non-nil, data is a frame whose length field or
+// checksum didn't match its contents.
+func decodeFrame(data []byte) (payload []byte, isFrame bool, err error) {
+	if len(data) < headerSize+checksumSize || string(data[:len(frameMagic)]) != frameMagic {
+		return nil, false, nil
+	}
+
+	if data[len(frameMagic)] != frameVersion {
+		return nil, false, nil
+	}
+
+	length := binary.BigEndian.Uint32(data[len(frameMagic)+1 : headerSize])
+	if headerSize+int(length)+checksumSize != len(data) {
+		return nil, true, errFrameTruncated
+	}
+
+	header := data[:headerSize]
+	payload = data[headerSize : headerSize+int(length)]
+	wantSum := data[headerSize+int(length):]
+
+	gotSum := sha256.Sum256(append(append([]byte{}, header...), payload...))
+	if !bytes.Equal(gotSum[:], wantSum) {
+		return nil, true, errChecksumMismatch
+	}
+
+	return payload, true, nil
+}
+
+const error_corrupted_entry = "corrupted entry"
+
+// IsCorrupted returns true if the error indicates the on-disk entry failed its checksum verification.
+func IsCorrupted(err error) bool {
+	return IsPStoreError(err) && strings.Contains(err.Error(), error_corrupted_entry)
+}
+
+// RepairPolicy decides what Repair does with a corrupted entry.
+type RepairPolicy int
+
+const (
+	// RepairDelete removes the corrupted entry outright.
+	RepairDelete RepairPolicy = iota
+	// RepairQuarantine moves the corrupted entry aside (key + ".corrupted") instead of deleting it.
+	RepairQuarantine
+)
+
+// verifyBackend returns the Backend VerifyAll/Repair should read from: the
+// same store diskBackend() uses, but bypassing any in-memory front cache, so
+// a key that's already been read or written once doesn't shadow corruption
+// that happened on disk afterwards. It also avoids populating front with
+// every scanned key, which VerifyAll doesn't otherwise touch.
+func (ps *PersistentStorage) verifyBackend() Backend {
+	if u, ok := ps.diskBackend().(uncachedBackend); ok {
+		return u.uncached()
+	}
+
+	return ps.diskBackend()
+}
+
+// VerifyAll walks every entry in the store and checks its checksum without
+// decoding it into the in-memory cache. Legacy entries written before
+// checksums were introduced can't be verified and are never reported as
+// corrupted.
+//
+// Returns:
+//   - A map from key to the error that flagged it as corrupted.
+//   - An error if the keys could not be enumerated.
+func (ps *PersistentStorage) VerifyAll() (map[string]error, error) {
+	unlock := ps.rlockAll()
+	defer unlock()
+
+	backend := ps.verifyBackend()
+
+	keys, err := backend.List()
+	if err != nil {
+		return nil, ps.errorf("%s: %v", error_read_files_failed, err)
+	}
+
+	corrupted := map[string]error{}
+	for _, key := range keys {
+		raw, err := backend.Get(key)
+		if err != nil {
+			corrupted[key] = ps.errorf("%s %v: %v", error_read_from_disk_failed, key, err)
+			continue
+		}
+
+		if _, isFrame, err := decodeFrame(raw); isFrame && err != nil {
+			corrupted[key] = ps.errorf("%s %v: %v", error_corrupted_entry, key, err)
+		}
+	}
+
+	return corrupted, nil
+}
+
+// Repair runs VerifyAll and applies policy to every corrupted entry it finds, also evicting it from the in-memory cache.
+//
+// Parameters:
+//   - policy: How to handle a corrupted entry.
+//
+// Returns:
+//   - The keys that were repaired, mapped to the error that flagged them.
+//   - An error if the corrupted entries could not be enumerated.
+func (ps *PersistentStorage) Repair(policy RepairPolicy) (map[string]error, error) {
+	corrupted, err := ps.VerifyAll()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := ps.lockAll()
+	defer unlock()
+
+	for key := range corrupted {
+		ps.evictFromMem(ps.shardFor(key), key)
+
+		if policy == RepairQuarantine {
+			if raw, err := ps.verifyBackend().Get(key); err == nil {
+				ps.diskBackend().Set(key+".corrupted", raw)
+			}
+		}
+
+		ps.diskBackend().Delete(key)
+	}
+
+	return corrupted, nil
+}
@@ -1,11 +1,12 @@
 package pstore
 
 import (
-	"os"
-	"path"
+	"errors"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type any = interface{}
@@ -26,16 +27,90 @@ func MemoryItemsCount(count int) memItemCount {
 type PersistentStorage struct {
 	// The maximum number of items to keep in memory.
 	MaxMemItems memItemCount
+	// The maximum number of serialized bytes to keep in memory. 0 means unlimited. Evaluated in addition to MaxMemItems.
+	MaxMemBytes int
+	// Decides which key to reclaim when MaxMemItems or MaxMemBytes is exceeded. Defaults to evicting an arbitrary key if nil.
+	EvictionPolicy EvictionPolicy
 	// If true, the cache will be thread-safe.
 	ThreadSafe bool
 	// If true, the cache will be saved to disk when a key is set. Default is true.
 	SaveToDiskOnSet bool
+	// Codec controls how values are marshaled to and from bytes before being stored. Defaults to GobCodec{}.
+	Codec Codec
+	// If true, all keys are stored in a single file on disk instead of one file per key, written atomically on every mutation. Ignored by NewInMemory.
+	SingleCacheFile bool
+	// NumShards controls how many independently-locked stripes (by FNV-1a hash of the key) the in-memory cache is split across; when ThreadSafe is true each stripe has its own lock, so unrelated keys stop contending for the same lock. Defaults to DEFAULT_NUM_SHARDS. Set to 1 to keep a single shard; required if EvictionPolicy is set, since Evict needs one consistent view of recency/frequency across the whole cache, and is forced to 1 automatically in that case.
+	NumShards int
+
+	path          string
+	name          string
+	inMemory      bool
+	backend       Backend
+	backendOnce   sync.Once
+	singleBackend Backend
+	shards        []*cacheShard
+	shardsOnce    sync.Once
+	policyMu      sync.Mutex
+	// totalItems and totalMemBytes mirror the combined size of every shard's
+	// cache, kept in sync by setInMemory/get/removeFromCache, so MaxMemItems and
+	// MaxMemBytes are enforced against the whole store instead of whichever
+	// shard happens to be under the caller's lock.
+	totalItems    int64
+	totalMemBytes int64
+}
+
+// diskBackend returns the Backend entries are actually read from and written
+// to, taking SingleCacheFile into account. The single-file backend is built
+// lazily so SingleCacheFile can be toggled any time before the first disk op, same as Codec.
+func (ps *PersistentStorage) diskBackend() Backend {
+	if ps.inMemory {
+		return ps.backend
+	}
+
+	if !ps.SingleCacheFile {
+		return ps.defaultBackend()
+	}
+
+	if ps.singleBackend == nil {
+		ps.singleBackend = NewSingleFileBackend(ps.path, ps.name)
+	}
+
+	return ps.singleBackend
+}
+
+// defaultBackend returns the read-through/write-through Backend New() uses,
+// building it lazily so its front cache's cap can be sized from MaxMemItems
+// as actually configured, rather than whatever it was when New() returned.
+// Built once, same as shardSet does for NumShards. MEM_ITEMS_UNLIMITED (-1)
+// flows straight through to newBoundedFrontBackend, which already treats any
+// cap <= 0 as unlimited.
+func (ps *PersistentStorage) defaultBackend() Backend {
+	ps.backendOnce.Do(func() {
+		ps.backend = NewCached(newBoundedFrontBackend(int(ps.MaxMemItems)), NewFSBackend(ps.path, ps.name))
+	})
+
+	return ps.backend
+}
+
+// shardSet returns the cache shards, building them on first use from
+// NumShards (defaulting to DEFAULT_NUM_SHARDS). EvictionPolicy needs a single
+// consistent view to pick the right key, so its presence forces one shard
+// regardless of NumShards.
+func (ps *PersistentStorage) shardSet() []*cacheShard {
+	ps.shardsOnce.Do(func() {
+		n := ps.NumShards
+		if n < 1 {
+			n = DEFAULT_NUM_SHARDS
+		}
+
+		if ps.EvictionPolicy != nil {
+			n = 1
+		}
+
+		ps.shards = newShards(n)
+	})
 
-	path     string
-	name     string
-	cache    map[string]any
-	inMemory bool
-	mutex    *sync.Mutex
+	return ps.shards
 }
 
 // New creates a new PersistentStorage instance.
@@ -50,12 +125,11 @@ func New(path, name string) *PersistentStorage {
 	return &PersistentStorage{
 		path:            path,
 		name:            name,
-		cache:           make(map[string]any),
 		MaxMemItems:     MEM_ITEMS_DEFAULT,
 		inMemory:        false,
 		SaveToDiskOnSet: true,
 		ThreadSafe:      false,
-		mutex:           new(sync.Mutex),
+		Codec:           GobCodec{},
 	}
 }
 
@@ -76,12 +150,12 @@ func New(path, name string) *PersistentStorage {
 func NewInMemory(name string) *PersistentStorage {
 	return &PersistentStorage{
 		name:            name,
-		cache:           make(map[string]any),
 		MaxMemItems:     MEM_ITEMS_UNLIMITED,
 		inMemory:        true,
+		backend:         NewMemoryBackend(),
 		SaveToDiskOnSet: false,
 		ThreadSafe:      false,
-		mutex:           new(sync.Mutex),
+		Codec:           GobCodec{},
 	}
 }
 
@@ -97,25 +171,22 @@ func IsReadFilesFailed(err error) bool {
 //   - The number of items in the storage.
 //   - An error if files could not be read.
 func (ps *PersistentStorage) Len() (int, error) {
-	if ps.ThreadSafe {
-		ps.mutex.Lock()
-		defer ps.mutex.Unlock()
-	}
+	unlock := ps.rlockAll()
+	defer unlock()
 
-	files, err := os.ReadDir(ps.path)
+	all, err := ps.diskBackend().List()
 	if err != nil {
 		return -1, ps.errorf("%s: %v", error_read_files_failed, err)
 	}
 
 	count := 0
-	for _, file := range files {
-		if file.IsDir() {
+	for _, key := range all {
+		if ps.isFileExpired(key) {
+			ps.diskBackend().Delete(key)
 			continue
 		}
 
-		if strings.HasPrefix(file.Name(), ps.name+"_") && strings.HasSuffix(file.Name(), cache_ext) {
-			count++
-		}
+		count++
 	}
 
 	return count, nil
@@ -136,13 +207,13 @@ func IsDeleteFailed(err error) bool {
 // Returns:
 //   - An error if the key does not exist or if the file could not be deleted.
 func (ps *PersistentStorage) Delete(key string) error {
-	delete(ps.cache, key)
+	shard := ps.shardFor(key)
+	unlock := ps.lock(shard)
+	defer unlock()
 
-	if ps.inMemory {
-		return nil
-	}
+	ps.evictFromMem(shard, key)
 
-	if err := os.RemoveAll(ps.getCachePath(key)); err != nil {
+	if err := ps.diskBackend().Delete(key); err != nil {
 		return ps.errorf("%s %v: %v", error_delete_failed, key, err)
 	}
 
@@ -158,33 +229,35 @@ func (ps *PersistentStorage) Delete(key string) error {
 //   - True if the key exists in the cache.
 //   - An error if the key could not be checked.
 func (ps *PersistentStorage) Has(key string) (bool, error) {
-	if ps.ThreadSafe {
-		ps.mutex.Lock()
-		defer ps.mutex.Unlock()
+	shard := ps.shardFor(key)
+
+	if found, handled := ps.hasCachedFast(shard, key); handled {
+		return found, nil
 	}
 
-	_, ok := ps.cache[key]
+	unlock := ps.lock(shard)
+	defer unlock()
+
+	ps.evictIfExpired(shard, key)
 
-	if ok {
+	if _, ok := shard.cache[key]; ok {
 		return true, nil
 	}
 
-	files, err := os.ReadDir(ps.path)
-	if err != nil {
-		return false, ps.errorf("%s: %v", error_read_files_failed, err)
+	if ps.isFileExpired(key) {
+		ps.diskBackend().Delete(key)
+		return false, nil
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	if _, err := ps.diskBackend().Get(key); err != nil {
+		if errors.Is(err, ErrBackendKeyNotFound) {
+			return false, nil
 		}
 
-		if ps.getCacheFilename(key) == file.Name() {
-			return true, nil
-		}
+		return false, ps.errorf("%s: %v", error_read_files_failed, err)
 	}
 
-	return false, nil
+	return true, nil
 }
 
 // Keys returns a list of all keys in the cache. Only the in-memory cache is counted. The keys are not sorted and the order is not guaranteed.
@@ -193,26 +266,22 @@ func (ps *PersistentStorage) Has(key string) (bool, error) {
 //   - A list of all keys in the cache.
 //   - An error if the keys could not be retrieved.
 func (ps *PersistentStorage) Keys() ([]string, error) {
-	if ps.ThreadSafe {
-		ps.mutex.Lock()
-		defer ps.mutex.Unlock()
-	}
+	unlock := ps.rlockAll()
+	defer unlock()
 
-	keys := []string{}
-
-	files, err := os.ReadDir(ps.path)
+	all, err := ps.diskBackend().List()
 	if err != nil {
 		return nil, ps.errorf("%s: %v", error_read_files_failed, err)
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
+	keys := []string{}
+	for _, key := range all {
+		if ps.isFileExpired(key) {
+			ps.diskBackend().Delete(key)
 			continue
 		}
 
-		if strings.HasPrefix(file.Name(), ps.name+"_") && strings.HasSuffix(file.Name(), cache_ext) {
-			keys = append(keys, strings.TrimSuffix(strings.TrimPrefix(file.Name(), ps.name+"_"), cache_ext))
-		}
+		keys = append(keys, key)
 	}
 
 	return keys, nil
@@ -227,48 +296,175 @@ func (ps *PersistentStorage) SaveToDisk() error {
 		return nil
 	}
 
-	if ps.ThreadSafe {
-		ps.mutex.Lock()
-		defer ps.mutex.Lock()
+	unlock := ps.rlockAll()
+	defer unlock()
+
+	if writer, ok := ps.diskBackend().(BatchWriter); ok {
+		sets := map[string][]byte{}
+		for _, shard := range ps.shardSet() {
+			for k, v := range shard.cache {
+				framed, err := ps.encodeForDisk(v, shard.expiry[k])
+				if err != nil {
+					return ps.errorf("%s %v: %v", error_serialize_failed, k, err)
+				}
+				sets[k] = framed
+			}
+		}
+
+		if err := writer.ApplyBatch(sets, nil); err != nil {
+			return ps.errorf("%s: %v", error_save_to_disk_failed, err)
+		}
+
+		return nil
 	}
 
-	for k, v := range ps.cache {
-		if err := ps.saveToDisk(k, v); err != nil {
-			return err
+	for _, shard := range ps.shardSet() {
+		for k, v := range shard.cache {
+			if err := ps.saveToDisk(k, v, shard.expiry[k]); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
-func (ps *PersistentStorage) set(key string, value any) error {
-	ps.cache[key] = value
-	if ps.SaveToDiskOnSet {
-		if err := ps.saveToDisk(key, value); err != nil {
-			return err
+// setInMemory applies key/value to the in-memory cache, size accounting,
+// expiry and eviction policy bookkeeping, without touching disk. It returns
+// the expiration computed from ttl, for callers that still need to persist it
+// themselves (set, Batch.Commit).
+func (ps *PersistentStorage) setInMemory(shard *cacheShard, key string, value any, ttl time.Duration) time.Time {
+	if _, existed := shard.cache[key]; !existed {
+		atomic.AddInt64(&ps.totalItems, 1)
+	}
+	shard.cache[key] = value
+
+	if ps.MaxMemBytes > 0 {
+		if size, err := ps.itemSize(value); err == nil {
+			delta := size - shard.sizes[key]
+			shard.memBytes += delta
+			shard.sizes[key] = size
+			atomic.AddInt64(&ps.totalMemBytes, int64(delta))
 		}
 	}
 
-	if ps.MaxMemItems != MEM_ITEMS_UNLIMITED && len(ps.cache) > int(ps.MaxMemItems) {
-		for k := range ps.cache {
-			delete(ps.cache, k)
-			break
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+		shard.expiry[key] = expiresAt
+	} else {
+		delete(shard.expiry, key)
+	}
+
+	ps.onPolicySet(key)
+
+	if ps.overMemLimit() {
+		if evictKey, ok := ps.nextEvictionKey(shard); ok {
+			ps.evictFromMem(shard, evictKey)
+		}
+	}
+
+	return expiresAt
+}
+
+func (ps *PersistentStorage) set(shard *cacheShard, key string, value any, ttl time.Duration) error {
+	expiresAt := ps.setInMemory(shard, key, value, ttl)
+
+	if ps.SaveToDiskOnSet {
+		if err := ps.saveToDisk(key, value, expiresAt); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-const cache_ext = ".pcache"
+// overMemLimit checks MaxMemItems/MaxMemBytes against totalItems/totalMemBytes,
+// the combined size across every shard, not just the one the caller holds a
+// lock on.
+func (ps *PersistentStorage) overMemLimit() bool {
+	if ps.MaxMemItems != MEM_ITEMS_UNLIMITED && atomic.LoadInt64(&ps.totalItems) > int64(ps.MaxMemItems) {
+		return true
+	}
+
+	if ps.MaxMemBytes > 0 && atomic.LoadInt64(&ps.totalMemBytes) > int64(ps.MaxMemBytes) {
+		return true
+	}
+
+	return false
+}
+
+func (ps *PersistentStorage) nextEvictionKey(shard *cacheShard) (string, bool) {
+	if ps.EvictionPolicy != nil {
+		return ps.EvictionPolicy.Evict()
+	}
+
+	for k := range shard.cache {
+		return k, true
+	}
 
-func (ps *PersistentStorage) getCacheFilename(key string) string {
-	return ps.name + "_" + key + cache_ext
+	return "", false
 }
 
-func (ps *PersistentStorage) getCachePath(key string) string {
-	return path.Join(ps.path, ps.getCacheFilename(key))
+// removeFromCache deletes key from shard's cache/expiry/sizes maps, keeping
+// totalItems/totalMemBytes in sync. It does not notify EvictionPolicy; use
+// evictFromMem for removals that should also update eviction bookkeeping.
+func (ps *PersistentStorage) removeFromCache(shard *cacheShard, key string) {
+	if _, existed := shard.cache[key]; existed {
+		delete(shard.cache, key)
+		atomic.AddInt64(&ps.totalItems, -1)
+	}
+	delete(shard.expiry, key)
+
+	if ps.MaxMemBytes > 0 {
+		atomic.AddInt64(&ps.totalMemBytes, -int64(shard.sizes[key]))
+		delete(shard.sizes, key)
+	}
 }
 
+func (ps *PersistentStorage) evictFromMem(shard *cacheShard, key string) {
+	ps.removeFromCache(shard, key)
+	ps.onPolicyDelete(key)
+}
+
+// onPolicyGet, onPolicySet and onPolicyDelete forward to EvictionPolicy under
+// policyMu, since a single EvictionPolicy instance is shared across all
+// shards and isn't safe for concurrent use on its own.
+func (ps *PersistentStorage) onPolicyGet(key string) {
+	if ps.EvictionPolicy == nil {
+		return
+	}
+
+	ps.policyMu.Lock()
+	defer ps.policyMu.Unlock()
+
+	ps.EvictionPolicy.OnGet(key)
+}
+
+func (ps *PersistentStorage) onPolicySet(key string) {
+	if ps.EvictionPolicy == nil {
+		return
+	}
+
+	ps.policyMu.Lock()
+	defer ps.policyMu.Unlock()
+
+	ps.EvictionPolicy.OnSet(key)
+}
+
+func (ps *PersistentStorage) onPolicyDelete(key string) {
+	if ps.EvictionPolicy == nil {
+		return
+	}
+
+	ps.policyMu.Lock()
+	defer ps.policyMu.Unlock()
+
+	ps.EvictionPolicy.OnDelete(key)
+}
+
+const cache_ext = ".pcache"
+
 const error_save_to_disk_failed = "failed to save"
 
 // IsSaveToDiskFailed returns true if the error is a save failure.
@@ -285,21 +481,39 @@ func IsSerializeFailed(err error) bool {
 
 const single_cache_filename = "single_full_cache"
 
-func (ps *PersistentStorage) saveToDisk(key string, value any) error {
-	if ps.inMemory {
-		return nil
+// encodeForDisk serializes value into the framed, enveloped bytes written to a Backend. Shared by saveToDisk and Batch.Commit so both go through the same codec and checksum framing.
+func (ps *PersistentStorage) encodeForDisk(value any, expiresAt time.Time) ([]byte, error) {
+	codec := ps.codec()
+
+	payload, err := codec.Marshal(value)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := os.MkdirAll(ps.path, 0755); err != nil {
-		return ps.errorf("%s %v: %v", error_save_to_disk_failed, key, err)
+	envelopeBytes, err := encodeEnvelope(cacheEnvelope{
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+		Payload:   payload,
+		Codec:     codec.Name(),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	bytes, err := serialize(value)
+	return encodeFrame(envelopeBytes), nil
+}
+
+func (ps *PersistentStorage) saveToDisk(key string, value any, expiresAt time.Time) error {
+	if ps.inMemory {
+		return nil
+	}
+
+	framed, err := ps.encodeForDisk(value, expiresAt)
 	if err != nil {
 		return ps.errorf("%s %v: %v", error_serialize_failed, key, err)
 	}
 
-	if err := os.WriteFile(ps.getCachePath(key), bytes, 0644); err != nil {
+	if err := ps.diskBackend().Set(key, framed); err != nil {
 		return ps.errorf("%s %v: %v", error_save_to_disk_failed, key, err)
 	}
 
@@ -313,26 +527,52 @@ func IsExpectedPointer(err error) bool {
 	return IsPStoreError(err) && strings.Contains(err.Error(), error_expected_pointer)
 }
 
-func (ps *PersistentStorage) get(out any, key string) error {
+func (ps *PersistentStorage) get(shard *cacheShard, out any, key string) error {
 	outReflect := reflect.ValueOf(out)
 	if outReflect.Kind() != reflect.Ptr {
 		return ps.errorf("%s but got type %v", error_expected_pointer, outReflect.Type())
 	}
 
-	it, ok := ps.cache[key]
+	ps.evictIfExpired(shard, key)
+
+	it, ok := shard.cache[key]
 
 	// If the key is not in the cache, read it from disk
 	if !ok {
-		err := ps.readFromDisk(out, key)
-
-		// If the key is found on disk, cache it
+		expiresAt, err := ps.readFromDisk(out, key)
 		if err != nil {
-			ps.cache[key] = outReflect.Elem().Interface()
+			return err
+		}
+
+		// The key was found on disk, cache it along with its expiration
+		value := outReflect.Elem().Interface()
+		atomic.AddInt64(&ps.totalItems, 1)
+		shard.cache[key] = value
+		if !expiresAt.IsZero() {
+			shard.expiry[key] = expiresAt
+		}
+
+		if ps.MaxMemBytes > 0 {
+			if size, err := ps.itemSize(value); err == nil {
+				shard.memBytes += size
+				shard.sizes[key] = size
+				atomic.AddInt64(&ps.totalMemBytes, int64(size))
+			}
 		}
 
-		return err
+		ps.onPolicyGet(key)
+
+		if ps.overMemLimit() {
+			if evictKey, ok := ps.nextEvictionKey(shard); ok {
+				ps.evictFromMem(shard, evictKey)
+			}
+		}
+
+		return nil
 	}
 
+	ps.onPolicyGet(key)
+
 	outReflect.Elem().Set(reflect.ValueOf(it))
 
 	return nil
@@ -359,25 +599,73 @@ func IsDeserializeFailed(err error) bool {
 	return IsPStoreError(err) && strings.Contains(err.Error(), error_deserialize_failed)
 }
 
-func (ps *PersistentStorage) readFromDisk(out interface{}, key string) error {
+// readFromDisk reads and decodes the cache file for key into out. It returns
+// the entry's expiration, or the zero time if the entry never expires. Files
+// written before TTL support was added aren't envelopes; those are read as
+// raw gob payloads with no expiration.
+func (ps *PersistentStorage) readFromDisk(out interface{}, key string) (time.Time, error) {
 	if ps.inMemory {
-		return ps.errorf("%s %v", error_key_not_found, key)
+		return time.Time{}, ps.errorf("%s %v", error_key_not_found, key)
 	}
 
-	bytes, err := os.ReadFile(ps.getCachePath(key))
+	raw, err := ps.diskBackend().Get(key)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return ps.errorf("%s %v", error_key_not_found, key)
+		if errors.Is(err, ErrBackendKeyNotFound) {
+			return time.Time{}, ps.errorf("%s %v", error_key_not_found, key)
 		}
 
-		return ps.errorf("%s %v: %v", error_read_from_disk_failed, key, err)
+		return time.Time{}, ps.errorf("%s %v: %v", error_read_from_disk_failed, key, err)
 	}
 
-	if err := deserialize(bytes, out); err != nil {
-		return ps.errorf("%s %v: %v", error_deserialize_failed, key, err)
+	envelopeBytes, isFrame, err := decodeFrame(raw)
+	if isFrame && err != nil {
+		return time.Time{}, ps.errorf("%s %v: %v", error_corrupted_entry, key, err)
+	}
+	if !isFrame {
+		// legacy file written before checksum framing was added
+		envelopeBytes = raw
 	}
 
-	return nil
+	env, err := decodeEnvelope(envelopeBytes)
+	if err != nil {
+		if err := deserialize(envelopeBytes, out); err != nil {
+			return time.Time{}, ps.errorf("%s %v: %v", error_deserialize_failed, key, err)
+		}
+
+		return time.Time{}, nil
+	}
+
+	if env.isExpired() {
+		ps.diskBackend().Delete(key)
+		return time.Time{}, ps.errorf("%s %v", error_key_not_found, key)
+	}
+
+	codecName := env.Codec
+	if codecName == "" {
+		// envelope written before pluggable codecs were added
+		codecName = (GobCodec{}).Name()
+	}
+
+	codec := codecByName(codecName)
+	if codec == nil {
+		return time.Time{}, ps.errorf("%s %v: unknown codec %q", error_deserialize_failed, key, codecName)
+	}
+
+	if err := codec.Unmarshal(env.Payload, out); err != nil {
+		return time.Time{}, ps.errorf("%s %v: %v", error_deserialize_failed, key, err)
+	}
+
+	return env.ExpiresAt, nil
+}
+
+// codec returns ps.Codec, defaulting to GobCodec{} for zero-value
+// PersistentStorage structs built without New/NewInMemory.
+func (ps *PersistentStorage) codec() Codec {
+	if ps.Codec == nil {
+		return GobCodec{}
+	}
+
+	return ps.Codec
 }
 
 // Set sets the value of the key in the cache. If the key already exists, it is overwritten. If the cache is thread-safe, the operation is atomic.
@@ -389,10 +677,9 @@ func (ps *PersistentStorage) readFromDisk(out interface{}, key string) error {
 // Returns:
 //   - An error if the value could not be set.
 func (ps *PersistentStorage) Set(key string, value any) error {
-	if ps.ThreadSafe {
-		ps.mutex.Lock()
-		defer ps.mutex.Lock()
-	}
+	shard := ps.shardFor(key)
+	unlock := ps.lock(shard)
+	defer unlock()
 
 	var it any = value
 
@@ -400,7 +687,7 @@ func (ps *PersistentStorage) Set(key string, value any) error {
 		it = reflect.ValueOf(it).Elem().Interface()
 	}
 
-	return ps.set(key, it)
+	return ps.set(shard, key, it, 0)
 }
 
 // Get gets the value of the key from the cache. If the key does not exist in the cache, it is read from disk. If the key does not exist on disk, an error is returned. If the cache is thread-safe, the operation is atomic.
@@ -412,10 +699,14 @@ func (ps *PersistentStorage) Set(key string, value any) error {
 // Returns:
 //   - An error if the value could not be retrieved.
 func (ps *PersistentStorage) Get(key string, out any) error {
-	if ps.ThreadSafe {
-		ps.mutex.Lock()
-		defer ps.mutex.Lock()
+	shard := ps.shardFor(key)
+
+	if ps.getCachedFast(shard, key, out) {
+		return nil
 	}
 
-	return ps.get(out, key)
+	unlock := ps.lock(shard)
+	defer unlock()
+
+	return ps.get(shard, out, key)
 }
@@ -1,9 +1,15 @@
 package pstore_test
 
 import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/saenai255/pstore"
 )
@@ -289,6 +295,532 @@ func Test_SaveToDisk(t *testing.T) {
 	assetCacheFileExists(t, "CacheName", "KeyName")
 }
 
+func Test_ExpiredTTLEntry_DoesNotLeaveEvictionPolicyGhost(t *testing.T) {
+	p := pstore.NewInMemory("CacheName")
+	p.EvictionPolicy = pstore.NewLRUPolicy()
+	p.MaxMemItems = pstore.MemoryItemsCount(2)
+
+	if err := p.SetWithTTL("Expiring", "Value", time.Millisecond); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if has, _ := p.Has("Expiring"); has {
+		t.Fatalf("expected expired key to report as absent")
+	}
+
+	if err := p.Set("a", "1"); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	if err := p.Set("b", "2"); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	// MaxMemItems is 2, so this Set must evict the actual least recently used
+	// live key ("a"), not a ghost entry the expired "Expiring" key left
+	// behind in the EvictionPolicy's own bookkeeping.
+	if err := p.Set("c", "3"); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	if has, _ := p.Has("a"); has {
+		t.Errorf("expected 'a' to have been evicted as least recently used")
+	}
+
+	if has, _ := p.Has("b"); !has {
+		t.Errorf("expected 'b' to still be resident")
+	}
+
+	if has, _ := p.Has("c"); !has {
+		t.Errorf("expected 'c' to still be resident")
+	}
+}
+
+func Test_LRUPolicy_EvictsLeastRecentlyUsed(t *testing.T) {
+	p := pstore.NewLRUPolicy()
+
+	p.OnSet("a")
+	p.OnSet("b")
+	p.OnSet("c")
+	p.OnGet("a")
+
+	key, ok := p.Evict()
+	if !ok || key != "b" {
+		t.Errorf("expected to evict 'b', got %v (ok=%v)", key, ok)
+	}
+}
+
+func Test_LFUPolicy_EvictsLeastFrequentlyUsed(t *testing.T) {
+	p := pstore.NewLFUPolicy()
+
+	p.OnSet("a")
+	p.OnSet("b")
+	p.OnGet("a")
+	p.OnGet("a")
+
+	key, ok := p.Evict()
+	if !ok || key != "b" {
+		t.Errorf("expected to evict 'b', got %v (ok=%v)", key, ok)
+	}
+
+	key, ok = p.Evict()
+	if !ok || key != "a" {
+		t.Errorf("expected to evict 'a', got %v (ok=%v)", key, ok)
+	}
+
+	if _, ok := p.Evict(); ok {
+		t.Errorf("expected no more keys to evict")
+	}
+}
+
+func Test_NewCached_ReadsThroughAndPopulatesFront(t *testing.T) {
+	front := pstore.NewMemoryBackend()
+	back := pstore.NewMemoryBackend()
+
+	if err := back.Set("KeyName", []byte("Value")); err != nil {
+		t.Errorf("failed to set key on back: %v", err)
+	}
+
+	cached := pstore.NewCached(front, back)
+
+	data, err := cached.Get("KeyName")
+	if err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	if string(data) != "Value" {
+		t.Errorf("got wrong value: %v", string(data))
+	}
+
+	if _, err := front.Get("KeyName"); err != nil {
+		t.Errorf("expected front to be populated after read-through miss: %v", err)
+	}
+}
+
+func Test_NewCached_WritesThroughToBack(t *testing.T) {
+	front := pstore.NewMemoryBackend()
+	back := pstore.NewMemoryBackend()
+	cached := pstore.NewCached(front, back)
+
+	if err := cached.Set("KeyName", []byte("Value")); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	data, err := back.Get("KeyName")
+	if err != nil {
+		t.Errorf("expected back to be written through: %v", err)
+	}
+
+	if string(data) != "Value" {
+		t.Errorf("got wrong value: %v", string(data))
+	}
+}
+
+func Test_SQLiteBackend_SetGet(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+	dbPath := TEST_ASSETS_PATH + "/sqlite_backend_setget.db"
+	t.Cleanup(func() { os.Remove(dbPath) })
+
+	backend, err := pstore.NewSQLiteBackend(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite backend: %v", err)
+	}
+	defer backend.Close()
+
+	if err := backend.Set("KeyName", []byte("Value")); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	data, err := backend.Get("KeyName")
+	if err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	if string(data) != "Value" {
+		t.Errorf("got wrong value: %v", string(data))
+	}
+}
+
+func Test_SQLiteBackend_DeleteAndList(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+	dbPath := TEST_ASSETS_PATH + "/sqlite_backend_delete.db"
+	t.Cleanup(func() { os.Remove(dbPath) })
+
+	backend, err := pstore.NewSQLiteBackend(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite backend: %v", err)
+	}
+	defer backend.Close()
+
+	if err := backend.Set("Key1", []byte("Value")); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+	if err := backend.Set("Key2", []byte("Value")); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	if err := backend.Delete("Key1"); err != nil {
+		t.Errorf("failed to delete key: %v", err)
+	}
+
+	if _, err := backend.Get("Key1"); !errors.Is(err, pstore.ErrBackendKeyNotFound) {
+		t.Errorf("expected ErrBackendKeyNotFound, got %v", err)
+	}
+
+	keys, err := backend.List()
+	if err != nil {
+		t.Errorf("failed to list keys: %v", err)
+	}
+
+	if len(keys) != 1 || keys[0] != "Key2" {
+		t.Errorf("expected only Key2 to remain, got %v", keys)
+	}
+}
+
+func Test_VerifyAll_DetectsCorruption(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+
+	if err := p.Set("KeyName", "Value"); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	file := TEST_ASSETS_PATH + "/CacheName_KeyName.pcache"
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read cache file: %v", err)
+	}
+
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf("failed to corrupt cache file: %v", err)
+	}
+
+	corrupted, err := p.VerifyAll()
+	if err != nil {
+		t.Errorf("failed to verify: %v", err)
+	}
+
+	corruptErr, ok := corrupted["KeyName"]
+	if !ok {
+		t.Errorf("expected KeyName to be reported as corrupted")
+	}
+
+	if !pstore.IsCorrupted(corruptErr) {
+		t.Errorf("expected IsCorrupted to be true for: %v", corruptErr)
+	}
+}
+
+func Test_Repair_DeletesCorruptedEntries(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+
+	if err := p.Set("KeyName", "Value"); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	file := TEST_ASSETS_PATH + "/CacheName_KeyName.pcache"
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read cache file: %v", err)
+	}
+
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf("failed to corrupt cache file: %v", err)
+	}
+
+	if _, err := p.Repair(pstore.RepairDelete); err != nil {
+		t.Errorf("failed to repair: %v", err)
+	}
+
+	assetCacheFileNotExists(t, "CacheName", "KeyName")
+}
+
+func Test_JSONCodec_SetGet(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	p.Codec = pstore.JSONCodec{}
+
+	if err := p.Set("KeyName", TestStruct{Value: "Hello"}); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	fresh := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	fresh.Codec = pstore.JSONCodec{}
+
+	var out TestStruct
+	if err := fresh.Get("KeyName", &out); err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	if out.Value != "Hello" {
+		t.Errorf("expected Hello, got %v", out.Value)
+	}
+}
+
+func Test_CompressingCodec_SetGet(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	p.Codec = pstore.CompressingCodec{Inner: pstore.JSONCodec{}}
+
+	if err := p.Set("KeyName", TestStruct{Value: "Hello"}); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	fresh := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	fresh.Codec = pstore.CompressingCodec{Inner: pstore.JSONCodec{}}
+
+	var out TestStruct
+	if err := fresh.Get("KeyName", &out); err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	if out.Value != "Hello" {
+		t.Errorf("expected Hello, got %v", out.Value)
+	}
+}
+
+func Test_Recode_MigratesCodecAndIsReadableByNewCodec(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	// Recode decodes each entry into an interface{} with its original codec,
+	// which GobCodec can't generally do without gob.Register. Start from
+	// JSONCodec, which can, to exercise the happy path.
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	p.Codec = pstore.JSONCodec{}
+
+	if err := p.Set("KeyName", TestStruct{Value: "Hello"}); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	newCodec := pstore.CompressingCodec{Inner: pstore.JSONCodec{}}
+	if err := p.Recode(newCodec); err != nil {
+		t.Errorf("failed to recode: %v", err)
+	}
+
+	fresh := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	fresh.Codec = newCodec
+
+	var out map[string]any
+	if err := fresh.Get("KeyName", &out); err != nil {
+		t.Errorf("failed to get key after recode: %v", err)
+	}
+
+	if out["Value"] != "Hello" {
+		t.Errorf("expected Hello, got %v", out["Value"])
+	}
+}
+
+func Test_SingleCacheFile_SetGet(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	p.SingleCacheFile = true
+
+	if err := p.Set("KeyName", "Value"); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	assetCacheFileNotExists(t, "CacheName", "KeyName")
+
+	file := TEST_ASSETS_PATH + "/CacheName_single_full_cache.pcache"
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("expected single cache file %v to exist: %v", file, err)
+	}
+
+	fresh := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	fresh.SingleCacheFile = true
+
+	var out string
+	if err := fresh.Get("KeyName", &out); err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	if out != "Value" {
+		t.Errorf("expected Value, got %v", out)
+	}
+}
+
+func Test_Batch_CommitAppliesSetsAndDeletesToSingleCacheFile(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	p.SingleCacheFile = true
+	p.SaveToDiskOnSet = false
+
+	if err := p.Set("ToDelete", "Gone"); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+	if err := p.SaveToDisk(); err != nil {
+		t.Errorf("failed to save to disk: %v", err)
+	}
+
+	if err := p.Batch().Set("KeyName", "Value").Delete("ToDelete").Commit(); err != nil {
+		t.Errorf("failed to commit batch: %v", err)
+	}
+
+	fresh := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	fresh.SingleCacheFile = true
+
+	var out string
+	if err := fresh.Get("KeyName", &out); err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+	if out != "Value" {
+		t.Errorf("expected Value, got %v", out)
+	}
+
+	if has, _ := fresh.Has("ToDelete"); has {
+		t.Errorf("expected ToDelete to have been removed by the batch")
+	}
+}
+
+func Test_Batch_SetDereferencesPointer(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+
+	if err := p.Batch().Set("KeyName", &TestStruct{Value: "value"}).Commit(); err != nil {
+		t.Errorf("failed to commit batch: %v", err)
+	}
+
+	var out TestStruct
+	if err := p.Get("KeyName", &out); err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	if out.Value != "value" {
+		t.Errorf("got wrong value: %v", out)
+	}
+}
+
+func Test_Get_ReadsLegacyFileWrittenBeforeChecksumFraming(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	if err := os.MkdirAll(TEST_ASSETS_PATH, 0755); err != nil {
+		t.Fatalf("failed to create test assets dir: %v", err)
+	}
+
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode("Value"); err != nil {
+		t.Fatalf("failed to gob-encode legacy payload: %v", err)
+	}
+
+	file := TEST_ASSETS_PATH + "/CacheName_KeyName.pcache"
+	if err := os.WriteFile(file, raw.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write legacy cache file: %v", err)
+	}
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+
+	var out string
+	if err := p.Get("KeyName", &out); err != nil {
+		t.Fatalf("failed to get legacy key: %v", err)
+	}
+
+	if out != "Value" {
+		t.Errorf("got wrong value: %v", out)
+	}
+}
+
+func Test_ThreadSafe_SetWithTTLAndGetWithMetadataDoNotDeadlock(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+	p.ThreadSafe = true
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if err := p.SetWithTTL("KeyName", "Value", time.Hour); err != nil {
+			t.Errorf("failed to set key: %v", err)
+			return
+		}
+
+		var out string
+		if _, err := p.GetWithMetadata("KeyName", &out); err != nil {
+			t.Errorf("failed to get key: %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SetWithTTL followed by GetWithMetadata deadlocked")
+	}
+}
+
+func Test_StartExpiryReaper_PurgesExpiredEntries(t *testing.T) {
+	t.Cleanup(ClearTestAssets)
+
+	p := pstore.New(TEST_ASSETS_PATH, "CacheName")
+
+	if err := p.SetWithTTL("KeyName", "Value", time.Millisecond); err != nil {
+		t.Errorf("failed to set key: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	stop := p.StartExpiryReaper(time.Millisecond)
+	defer stop()
+
+	file := TEST_ASSETS_PATH + "/CacheName_KeyName.pcache"
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Errorf("expected expired key's cache file to be reaped within 1s, but it is still present")
+}
+
+func Test_ThreadSafe_ConcurrentSetGetIsRaceFree(t *testing.T) {
+	p := pstore.NewInMemory("CacheName")
+	p.ThreadSafe = true
+
+	const goroutines = 8
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("key-%d", g)
+			for i := 0; i < iterations; i++ {
+				value := fmt.Sprintf("value-%d-%d", g, i)
+				if err := p.Set(key, value); err != nil {
+					t.Errorf("failed to set key: %v", err)
+					return
+				}
+
+				var out string
+				if err := p.Get(key, &out); err != nil {
+					t.Errorf("failed to get key: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+}
+
 func ClearTestAssets() {
 	files, err := os.ReadDir(TEST_ASSETS_PATH)
 	if err != nil {
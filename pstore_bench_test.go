@@ -0,0 +1,85 @@
+package pstore_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/saenai255/pstore"
+)
+
+// benchmarkConcurrentSetGet hammers p with concurrent Set/Get calls spread
+// across a fixed key space, so contention differences between shard counts
+// show up in the reported ns/op.
+func benchmarkConcurrentSetGet(b *testing.B, p *pstore.PersistentStorage) {
+	p.Set("seed", "value")
+
+	var counter uint64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := atomic.AddUint64(&counter, 1)
+			key := fmt.Sprintf("key-%d", n%64)
+
+			if err := p.Set(key, "value"); err != nil {
+				b.Fatalf("failed to set key: %v", err)
+			}
+
+			var out string
+			if err := p.Get(key, &out); err != nil {
+				b.Fatalf("failed to get key: %v", err)
+			}
+		}
+	})
+}
+
+// Benchmark_ConcurrentSetGet_SingleShard pins NumShards to 1, so every
+// goroutine contends for the same RWMutex.
+func Benchmark_ConcurrentSetGet_SingleShard(b *testing.B) {
+	p := pstore.NewInMemory("BenchCacheName")
+	p.ThreadSafe = true
+	p.NumShards = 1
+
+	benchmarkConcurrentSetGet(b, p)
+}
+
+// Benchmark_ConcurrentSetGet_DefaultShards uses the default shard count, so
+// unrelated keys land on independent locks.
+func Benchmark_ConcurrentSetGet_DefaultShards(b *testing.B) {
+	p := pstore.NewInMemory("BenchCacheName")
+	p.ThreadSafe = true
+
+	benchmarkConcurrentSetGet(b, p)
+}
+
+// Benchmark_ConcurrentSetGet_ManyShards doubles DEFAULT_NUM_SHARDS to show
+// contention keeps dropping as the stripe count grows.
+func Benchmark_ConcurrentSetGet_ManyShards(b *testing.B) {
+	p := pstore.NewInMemory("BenchCacheName")
+	p.ThreadSafe = true
+	p.NumShards = 2 * pstore.DEFAULT_NUM_SHARDS
+
+	benchmarkConcurrentSetGet(b, p)
+}
+
+// Benchmark_ConcurrentGet_SameKeySingleShard hammers a single, already-cached
+// key on a single-shard store with concurrent Gets and nothing else. Cached
+// reads only take the shard's RLock, so this scales with GOMAXPROCS instead
+// of fully serializing the way a plain Mutex (or a Set+Get mix) would.
+func Benchmark_ConcurrentGet_SameKeySingleShard(b *testing.B) {
+	p := pstore.NewInMemory("BenchCacheName")
+	p.ThreadSafe = true
+	p.NumShards = 1
+	p.Set("key", "value")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var out string
+		for pb.Next() {
+			if err := p.Get("key", &out); err != nil {
+				b.Fatalf("failed to get key: %v", err)
+			}
+		}
+	})
+}
@@ -5,16 +5,31 @@ import (
 	"encoding/gob"
 )
 
-func serialize(it interface{}) ([]byte, error) {
+// GobCodec encodes values with encoding/gob, the default Codec. It's compact for Go-to-Go caches but requires both ends to share the same registered Go types.
+type GobCodec struct{}
+
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+func (GobCodec) Marshal(value any) ([]byte, error) {
 	writer := new(bytes.Buffer)
 	enc := gob.NewEncoder(writer)
-	err := enc.Encode(it)
+	err := enc.Encode(value)
 
 	return writer.Bytes(), err
 }
 
-func deserialize(data []byte, out interface{}) error {
+func (GobCodec) Unmarshal(data []byte, out any) error {
 	reader := bytes.NewReader(data)
 	dec := gob.NewDecoder(reader)
 	return dec.Decode(out)
 }
+
+func serialize(it interface{}) ([]byte, error) {
+	return GobCodec{}.Marshal(it)
+}
+
+func deserialize(data []byte, out interface{}) error {
+	return GobCodec{}.Unmarshal(data, out)
+}
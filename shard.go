@@ -0,0 +1,156 @@
+package pstore
+
+import (
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DEFAULT_NUM_SHARDS is used when NumShards is left at its zero value.
+const DEFAULT_NUM_SHARDS = 16
+
+// cacheShard holds one stripe of the in-memory cache, each guarded by its own
+// RWMutex so keys in different shards never contend for the same lock.
+type cacheShard struct {
+	mu       sync.RWMutex
+	cache    map[string]any
+	expiry   map[string]time.Time
+	sizes    map[string]int
+	memBytes int
+}
+
+func newShards(n int) []*cacheShard {
+	if n < 1 {
+		n = 1
+	}
+
+	shards := make([]*cacheShard, n)
+	for i := range shards {
+		shards[i] = &cacheShard{
+			cache:  make(map[string]any),
+			expiry: make(map[string]time.Time),
+			sizes:  make(map[string]int),
+		}
+	}
+
+	return shards
+}
+
+// fnv1a hashes key with FNV-1a, used to pick the shard a key belongs to.
+func fnv1a(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+func (ps *PersistentStorage) shardFor(key string) *cacheShard {
+	shards := ps.shardSet()
+	if len(shards) == 1 {
+		return shards[0]
+	}
+
+	return shards[fnv1a(key)%uint32(len(shards))]
+}
+
+// rlock read-locks shard if ThreadSafe is enabled and returns the matching unlock func.
+func (ps *PersistentStorage) rlock(s *cacheShard) func() {
+	if !ps.ThreadSafe {
+		return func() {}
+	}
+
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// lock write-locks shard if ThreadSafe is enabled and returns the matching unlock func.
+func (ps *PersistentStorage) lock(s *cacheShard) func() {
+	if !ps.ThreadSafe {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// hasCachedFast takes only shard's RLock to report whether key is a live,
+// non-expired cache hit. handled is false on a miss or an expired entry,
+// since those need evictIfExpired and a possible disk check, both of which
+// require the shard's write lock; the caller falls back to the slow path.
+func (ps *PersistentStorage) hasCachedFast(shard *cacheShard, key string) (found, handled bool) {
+	unlock := ps.rlock(shard)
+	defer unlock()
+
+	if expiresAt, ok := shard.expiry[key]; ok && !time.Now().Before(expiresAt) {
+		return false, false
+	}
+
+	_, ok := shard.cache[key]
+	return ok, ok
+}
+
+// getCachedFast takes only shard's RLock to serve a live, non-expired cache
+// hit into out, reporting whether it did. On a miss or expired entry it
+// reports false so the caller falls back to get(), which takes the shard's
+// write lock: populating from disk and evicting an expired entry both mutate
+// shard state and can't safely happen under a shared read lock. The
+// EvictionPolicy update on a hit is serialized by policyMu instead.
+func (ps *PersistentStorage) getCachedFast(shard *cacheShard, key string, out any) bool {
+	outReflect := reflect.ValueOf(out)
+	if outReflect.Kind() != reflect.Ptr {
+		return false
+	}
+
+	unlock := ps.rlock(shard)
+	defer unlock()
+
+	if expiresAt, ok := shard.expiry[key]; ok && !time.Now().Before(expiresAt) {
+		return false
+	}
+
+	it, ok := shard.cache[key]
+	if !ok {
+		return false
+	}
+
+	outReflect.Elem().Set(reflect.ValueOf(it))
+	ps.onPolicyGet(key)
+
+	return true
+}
+
+// rlockAll read-locks every shard, in a fixed order to avoid deadlocking
+// against a concurrent lockAll, and returns the matching unlock func.
+func (ps *PersistentStorage) rlockAll() func() {
+	if !ps.ThreadSafe {
+		return func() {}
+	}
+
+	for _, s := range ps.shardSet() {
+		s.mu.RLock()
+	}
+
+	return func() {
+		for _, s := range ps.shardSet() {
+			s.mu.RUnlock()
+		}
+	}
+}
+
+// lockAll write-locks every shard, in a fixed order to avoid deadlocking
+// against a concurrent lockAll, and returns the matching unlock func.
+func (ps *PersistentStorage) lockAll() func() {
+	if !ps.ThreadSafe {
+		return func() {}
+	}
+
+	for _, s := range ps.shardSet() {
+		s.mu.Lock()
+	}
+
+	return func() {
+		for _, s := range ps.shardSet() {
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package pstore
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test_MaxMemItems_BoundsAcrossShards is a white-box regression test for a bug
+// where overMemLimit compared MaxMemItems against a single shard's map
+// instead of the cache as a whole, letting a default 16-shard store hold up
+// to 16x MaxMemItems resident in memory.
+func Test_MaxMemItems_BoundsAcrossShards(t *testing.T) {
+	p := NewInMemory("InternalTestCache")
+	p.MaxMemItems = MemoryItemsCount(10)
+
+	for i := 0; i < 200; i++ {
+		if err := p.Set(fmt.Sprintf("key-%d", i), "value"); err != nil {
+			t.Fatalf("failed to set key: %v", err)
+		}
+	}
+
+	total := 0
+	for _, shard := range p.shardSet() {
+		total += len(shard.cache)
+	}
+
+	if total > 10 {
+		t.Errorf("expected at most 10 items resident across all shards, got %d", total)
+	}
+}
@@ -0,0 +1,201 @@
+package pstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path"
+	"sync"
+)
+
+// atomicWriteFile writes data to a temp file in the same directory as name
+// and renames it into place, so a crash or concurrent read never observes a
+// partially written file. The temp file is fsynced before the rename and its
+// directory after it, so the write survives a crash, not just a process exit.
+func atomicWriteFile(name string, data []byte, perm os.FileMode) error {
+	dir := path.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+path.Base(name)+"-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, name); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if dirHandle, err := os.Open(dir); err == nil {
+		dirHandle.Sync()
+		dirHandle.Close()
+	}
+
+	return nil
+}
+
+// BatchWriter is implemented by backends that can apply several writes and
+// deletes as a single atomic operation. Backends that don't implement it have
+// their Sets and Deletes applied one at a time by Batch.Commit.
+type BatchWriter interface {
+	// ApplyBatch writes every key in sets and removes every key in deletes.
+	ApplyBatch(sets map[string][]byte, deletes []string) error
+}
+
+type singleFileBackend struct {
+	mutex sync.Mutex
+	path  string
+	name  string
+}
+
+// NewSingleFileBackend creates a Backend that keeps every key in one
+// "<name>_single_full_cache.pcache" file under path instead of one file per
+// key. Every Set and Delete rewrites the whole file atomically via
+// atomicWriteFile; ApplyBatch lets many changes share a single rewrite.
+func NewSingleFileBackend(path, name string) Backend {
+	return &singleFileBackend{path: path, name: name}
+}
+
+func (b *singleFileBackend) filename() string {
+	return b.name + "_" + single_cache_filename + cache_ext
+}
+
+func (b *singleFileBackend) fullPath() string {
+	return path.Join(b.path, b.filename())
+}
+
+func (b *singleFileBackend) load() (map[string][]byte, error) {
+	data, err := os.ReadFile(b.fullPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string][]byte{}, nil
+		}
+
+		return nil, err
+	}
+
+	contents := map[string][]byte{}
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&contents); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+func (b *singleFileBackend) save(contents map[string][]byte) error {
+	writer := new(bytes.Buffer)
+	enc := gob.NewEncoder(writer)
+	if err := enc.Encode(contents); err != nil {
+		return err
+	}
+
+	return atomicWriteFile(b.fullPath(), writer.Bytes(), 0644)
+}
+
+func (b *singleFileBackend) Get(key string) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	contents, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := contents[key]
+	if !ok {
+		return nil, ErrBackendKeyNotFound
+	}
+
+	return data, nil
+}
+
+func (b *singleFileBackend) Set(key string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	contents, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	contents[key] = data
+
+	return b.save(contents)
+}
+
+func (b *singleFileBackend) Delete(key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	contents, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	delete(contents, key)
+
+	return b.save(contents)
+}
+
+func (b *singleFileBackend) List() ([]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	contents, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(contents))
+	for k := range contents {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (b *singleFileBackend) ApplyBatch(sets map[string][]byte, deletes []string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	contents, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	for key, data := range sets {
+		contents[key] = data
+	}
+
+	for _, key := range deletes {
+		delete(contents, key)
+	}
+
+	return b.save(contents)
+}
@@ -0,0 +1,81 @@
+package pstore
+
+import (
+	"database/sql"
+	"errors"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteBackend stores every key in a single SQLite database file instead of
+// one file per key, trading per-key atomic renames for a single durable
+// table.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// NewSQLiteBackend opens (creating if needed) a SQLite database at path and
+// returns a Backend backed by a single "cache" table, so one file can hold
+// an entire PersistentStorage instead of one .pcache file per key.
+func NewSQLiteBackend(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS cache (key TEXT PRIMARY KEY, data BLOB NOT NULL)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteBackend{db: db}, nil
+}
+
+func (b *SQLiteBackend) Get(key string) ([]byte, error) {
+	var data []byte
+
+	err := b.db.QueryRow(`SELECT data FROM cache WHERE key = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBackendKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (b *SQLiteBackend) Set(key string, data []byte) error {
+	_, err := b.db.Exec(`INSERT INTO cache (key, data) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET data = excluded.data`, key, data)
+	return err
+}
+
+func (b *SQLiteBackend) Delete(key string) error {
+	_, err := b.db.Exec(`DELETE FROM cache WHERE key = ?`, key)
+	return err
+}
+
+func (b *SQLiteBackend) List() ([]string, error) {
+	rows, err := b.db.Query(`SELECT key FROM cache`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Close releases the underlying SQLite connection.
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}